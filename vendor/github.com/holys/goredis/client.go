@@ -1,47 +1,105 @@
 package goredis
 
 import (
-	"container/list"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
 	"io"
 	"net"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
-	"log"
-	"os"
 )
 
+// ErrClosed is returned by Get/Put once the pool has been closed.
+var ErrClosed = errors.New("goredis: pool is closed")
+
+// checkIdleConcurrency bounds how many idle conns are pinged at once by
+// onCheck, so a large idle pool doesn't open a burst of PINGs every tick.
+const checkIdleConcurrency = 4
+
+// Pool is the interface implemented by Client's connection pool.
+type Pool interface {
+	Get() (*PoolConn, error)
+	Put(*Conn) error
+	Len() int
+	Close() error
+}
+
 type PoolConn struct {
 	*Conn
 	c *Client
+
+	mu       sync.Mutex
+	unusable bool
 }
 
+// Close returns the connection to the pool, unless it has been marked
+// unusable or is already closed, in which case it is discarded.
 func (c *PoolConn) Close() {
-	if c.Conn.isClosed() {
+	c.mu.Lock()
+	unusable := c.unusable
+	c.mu.Unlock()
+
+	if unusable || c.Conn.isClosed() {
+		c.Conn.Close()
 		return
 	}
 
-	c.c.put(c.Conn)
+	c.c.Put(c.Conn)
 }
 
-// force close inner connection and not put it into pool
+// Finalize closes the underlying connection without returning it to the pool.
 func (c *PoolConn) Finalize() {
 	c.Conn.Close()
 }
 
+// MarkUnusable flags the connection as broken so the next Close discards it
+// instead of returning it to the pool.
+func (c *PoolConn) MarkUnusable() {
+	c.mu.Lock()
+	c.unusable = true
+	c.mu.Unlock()
+}
+
 type Client struct {
-	sync.Mutex
+	mu sync.RWMutex
 
 	addr            string
-	maxIdleConns    int
+	password        string
+	db              int
 	readBufferSize  int
 	writeBufferSize int
-	password        string
 
-	conns *list.List
+	tlsConfig    *tls.Config
+	dialTimeout  time.Duration
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+	idleTimeout  time.Duration
+
+	initialCap int
+	maxCap     int
+	factory    func() (*Conn, error)
+
+	conns chan *Conn
+
+	maxRetries   int32
+	shuttingDown int32 // atomic; set by Shutdown to stop new get() calls
+	inFlight     sync.WaitGroup
 
-	quit chan struct{}
-	wg   sync.WaitGroup
+	loggerV atomic.Value // holds a Logger; read/written via (*Client).log/SetLogger
+
+	totalConns  int64
+	hits        int64
+	misses      int64
+	timeouts    int64
+	staleClosed int64
+
+	initOnce sync.Once
+	quit     chan struct{}
+	wg       sync.WaitGroup
 }
 
 func getProto(addr string) string {
@@ -53,21 +111,23 @@ func getProto(addr string) string {
 }
 
 func NewClient(addr string, password string) *Client {
-	c := new(Client)
-
-	c.addr = addr
-	c.maxIdleConns = 10
-	c.readBufferSize = 2048
-	c.writeBufferSize = 2048
-	c.password = password
+	return NewClientWithOptions(&ClientOptions{Addr: addr, Password: password})
+}
 
-	c.conns = list.New()
-	c.quit = make(chan struct{})
+func (c *Client) defaultFactory() (*Conn, error) {
+	co, err := c.newConn(c.addr, c.password)
+	if err != nil {
+		return nil, err
+	}
 
-	c.wg.Add(1)
-	go c.onCheck() //ping不通会关闭链接，导致报错
+	if c.db != 0 {
+		if _, err := co.Do("SELECT", c.db); err != nil {
+			co.Close()
+			return nil, err
+		}
+	}
 
-	return c
+	return co, nil
 }
 
 func (c *Client) SetPassword(pass string) {
@@ -82,98 +142,323 @@ func (c *Client) SetWriteBufferSize(s int) {
 	c.writeBufferSize = s
 }
 
+// SetMaxIdleConns sets the pool's capacity, i.e. the maximum number of idle
+// connections it will hold onto. Must be called before the first Get/Put.
 func (c *Client) SetMaxIdleConns(n int) {
-	c.maxIdleConns = n
+	c.mu.Lock()
+	c.maxCap = n
+	c.mu.Unlock()
+}
+
+// SetInitialIdleConns sets how many connections are dialed eagerly when the
+// pool is first used. Must be called before the first Get/Put.
+func (c *Client) SetInitialIdleConns(n int) {
+	c.mu.Lock()
+	c.initialCap = n
+	c.mu.Unlock()
+}
+
+// SetFactory overrides how the pool dials new connections, letting tests
+// inject a fake. Must be called before the first Get/Put.
+func (c *Client) SetFactory(factory func() (*Conn, error)) {
+	c.mu.Lock()
+	c.factory = factory
+	c.mu.Unlock()
+}
+
+// SetMaxRetries sets how many attempts in total DoContext/Do make at a
+// command when the connection it picked turns out to be dead; n <= 0 means
+// a single attempt.
+func (c *Client) SetMaxRetries(n int) {
+	atomic.StoreInt32(&c.maxRetries, int32(n))
+}
+
+// isRetryableErr reports whether err indicates the connection itself died
+// rather than the command failing, so it's safe to retry on a fresh conn.
+func isRetryableErr(err error) bool {
+	if err == io.EOF {
+		return true
+	}
+	if e, ok := err.(*net.OpError); ok && strings.Contains(e.Error(), "use of closed network connection") {
+		return true
+	}
+	return false
+}
+
+// applyDeadline arranges for co's in-flight read/write to be aborted when
+// ctx is canceled or its deadline passes, and returns a func that must be
+// called to stop watching ctx once the call using co has returned.
+func (c *Client) applyDeadline(ctx context.Context, co *Conn) func() {
+	if ctx == nil || ctx.Done() == nil {
+		return func() {}
+	}
+
+	if dl, ok := ctx.Deadline(); ok {
+		co.SetDeadline(dl)
+	}
+
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			// force the in-flight read/write to fail immediately
+			co.SetDeadline(time.Unix(0, 1))
+		case <-stop:
+		}
+	}()
+
+	return func() { close(stop) }
+}
+
+// init lazily builds the channel pool and eagerly fills it with InitialCap
+// connections, so SetInitialIdleConns/SetFactory can still take effect when
+// called after NewClient but before the first Get/Put.
+func (c *Client) init() {
+	c.initOnce.Do(func() {
+		c.mu.Lock()
+		if atomic.LoadInt32(&c.shuttingDown) != 0 {
+			c.mu.Unlock()
+			return
+		}
+		if c.maxCap <= 0 {
+			c.maxCap = 10
+		}
+		if c.initialCap > c.maxCap {
+			c.initialCap = c.maxCap
+		}
+		initialCap, factory := c.initialCap, c.factory
+		c.conns = make(chan *Conn, c.maxCap)
+		c.mu.Unlock()
+
+		for i := 0; i < initialCap; i++ {
+			co, err := factory()
+			if err != nil {
+				continue
+			}
+			atomic.AddInt64(&c.totalConns, 1)
+			c.conns <- co
+		}
+
+		c.wg.Add(1)
+		go c.onCheck()
+	})
 }
 
 func (c *Client) Do(cmd string, args ...interface{}) (interface{}, error) {
-	var co *Conn
-	var err error
-	var r interface{}
+	return c.DoContext(context.Background(), cmd, args...)
+}
+
+// DoContext runs cmd like Do, but honors ctx: if ctx is canceled or its
+// deadline passes while the command is in flight, the underlying conn's
+// read/write deadline is tripped so the call returns promptly. A conn that
+// turns out to be dead (closed/EOF) is discarded and the command retried on
+// a fresh one, for up to Client.SetMaxRetries attempts in all.
+func (c *Client) DoContext(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	// Checking shuttingDown and registering with inFlight must happen under
+	// the same lock Shutdown takes before it calls inFlight.Wait(): without
+	// it, this goroutine could observe shuttingDown == 0, then Shutdown sets
+	// it and starts waiting on a counter that's briefly zero, then this
+	// goroutine calls Add(1) after the Wait has already begun — a data race
+	// sync.WaitGroup explicitly disallows (Add with positive delta racing a
+	// Wait that can observe a zero counter).
+	c.mu.RLock()
+	if atomic.LoadInt32(&c.shuttingDown) != 0 {
+		c.mu.RUnlock()
+		return nil, ErrClosed
+	}
+	c.inFlight.Add(1)
+	c.mu.RUnlock()
+	defer c.inFlight.Done()
+
+	maxRetries := int(atomic.LoadInt32(&c.maxRetries))
+	if maxRetries <= 0 {
+		maxRetries = 1
+	}
 
-	for i := 0; i < 2; i++ {
-		co, err = c.get()
+	var lastErr error
+	for i := 0; i < maxRetries; i++ {
+		co, err := c.get()
 		if err != nil {
 			return nil, err
 		}
 
-		r, err = co.Do(cmd, args...)
+		cancel := c.applyDeadline(ctx, co)
+		r, err := co.Do(cmd, args...)
+		cancel()
+
 		if err != nil {
-			if e, ok := err.(*net.OpError); ok && strings.Contains(e.Error(), "use of closed network connection") {
-				//send to a closed connection, try again
-				log.Panic(err)
-				os.Exit(-1)
-				//continue
-				return nil,err
+			if isRetryableErr(err) {
+				atomic.AddInt64(&c.staleClosed, 1)
+				if ctx.Err() != nil {
+					atomic.AddInt64(&c.timeouts, 1)
+				}
+				co.Close()
+				lastErr = err
+				c.log().Warn("goredis: retrying command on a fresh conn", "cmd", cmd, "attempt", i+1, "err", err)
+				continue
 			}
-			c.put(co)
+			c.Put(co)
 			return nil, err
 		}
 
-		c.put(co)
+		c.Put(co)
 		return r, nil
 	}
 
-	return nil, err
+	return nil, fmt.Errorf("goredis: %q failed after %d attempts: %v", cmd, maxRetries, lastErr)
 }
 
-func (c *Client) Monitor(respChan chan interface{}, stopChan chan struct{},closeChan chan struct{}) error {
-	var co *Conn
-	var err error
-
-	co, err = c.get()
+// Monitor is built atop the same runReadLoop primitive PubSub's reader uses:
+// read, classify, and either report a dead conn via stopChan or hand the
+// caller a reply.
+func (c *Client) Monitor(respChan chan interface{}, stopChan chan struct{}, closeChan chan struct{}) error {
+	co, err := c.get()
 	if err != nil {
 		return err
 	}
 
 	if err := co.Send("MONITOR"); err != nil {
+		c.Put(co)
 		return err
 	}
 
 	go func() {
-		defer func() {
-			c.put(co)
-		}()
-		for {
-			select {
-			case <- closeChan:{
-				//co.Close()
-				stopChan <- struct{}{}
-				return
-			}
-			default:{
-				resp, err := co.Receive()
-				if err != nil {
-					if e, ok := err.(*net.OpError); ok && strings.Contains(e.Error(), "use of closed network connection 2") || err == io.EOF {
-						//the server may has closed the connection
-						stopChan <- struct{}{}
-						return
-					}
-					respChan <- err
+		defer c.Put(co)
+
+		runReadLoop(closeChan,
+			func() (interface{}, error) { return co.Receive() },
+			func() { stopChan <- struct{}{} },
+			func(resp interface{}) bool {
+				respChan <- resp
+				return true
+			},
+			func(err error) bool {
+				if e, ok := err.(*net.OpError); ok && strings.Contains(e.Error(), "use of closed network connection 2") || err == io.EOF {
+					//the server may has closed the connection
+					c.log().Warn("goredis: monitor conn lost", "err", err)
+					stopChan <- struct{}{}
+					return false
 				}
+				c.log().Error("goredis: monitor receive error", "err", err)
+				respChan <- err
+				return true
+			},
+		)
+	}()
+
+	return nil
+}
+
+// MonitorContext is Monitor's context-aware counterpart, also built atop
+// runReadLoop: it stops on its own once ctx is done instead of requiring a
+// caller-managed closeChan, and aborts an in-flight Receive by tripping the
+// conn's deadline when ctx fires.
+func (c *Client) MonitorContext(ctx context.Context, respChan chan interface{}) error {
+	co, err := c.get()
+	if err != nil {
+		return err
+	}
+
+	if err := co.Send("MONITOR"); err != nil {
+		c.Put(co)
+		return err
+	}
+
+	go func() {
+		defer c.Put(co)
+
+		runReadLoop(ctx.Done(),
+			func() (interface{}, error) {
+				cancel := c.applyDeadline(ctx, co)
+				defer cancel()
+				return co.Receive()
+			},
+			nil,
+			func(resp interface{}) bool {
 				respChan <- resp
-			}
-			}
-		}
+				return true
+			},
+			func(err error) bool {
+				if ctx.Err() != nil || isRetryableErr(err) {
+					c.log().Warn("goredis: monitor conn lost", "err", err)
+					return false
+				}
+				c.log().Error("goredis: monitor receive error", "err", err)
+				respChan <- err
+				return true
+			},
+		)
 	}()
 
 	return nil
 }
 
-func (c *Client) Close() {
-	c.Lock()
-	defer c.Unlock()
+// Shutdown stops the pool from handing out new connections, waits for
+// commands already in flight (via DoContext/Do) to finish, then closes the
+// pool. If ctx is done before the drain completes, Shutdown closes the pool
+// immediately instead of waiting further.
+func (c *Client) Shutdown(ctx context.Context) error {
+	// Taking the write lock around the CAS excludes any DoContext call that's
+	// mid-way through its own RLock-guarded check-and-Add: it either finished
+	// registering with inFlight before this runs, or it will see
+	// shuttingDown != 0 and never register at all. See DoContext's comment.
+	c.mu.Lock()
+	swapped := atomic.CompareAndSwapInt32(&c.shuttingDown, 0, 1)
+	c.mu.Unlock()
+	if !swapped {
+		return nil
+	}
+
+	c.log().Info("goredis: shutting down", "addr", c.addr)
+
+	drained := make(chan struct{})
+	go func() {
+		c.inFlight.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return c.Close()
+	case <-ctx.Done():
+		c.Close()
+		return ctx.Err()
+	}
+}
+
+// Close shuts the pool down: it stops the idle-checker, drains the channel
+// and closes every connection still sitting in it. Safe to call before the
+// pool has ever been used (i.e. before the first Get/Put triggers init): it
+// still marks the client closed via shuttingDown, so a racing or subsequent
+// Get/init can't undo the Close by lazily building a fresh pool.
+func (c *Client) Close() error {
+	atomic.StoreInt32(&c.shuttingDown, 1)
+
+	c.mu.Lock()
+	conns := c.conns
+	c.conns = nil
+	c.mu.Unlock()
+
+	if conns == nil {
+		// init() hasn't run yet (or already closed once): there's no quit
+		// channel/checker goroutine to stop and nothing queued in conns to
+		// close. The store above is what makes this Close stick regardless,
+		// since get()/Put() both check shuttingDown before calling init(),
+		// and init() checks it again under c.mu before building the pool.
+		return nil
+	}
 
 	close(c.quit)
 	c.wg.Wait()
 
-	for c.conns.Len() > 0 {
-		e := c.conns.Front()
-		co := e.Value.(*Conn)
-		c.conns.Remove(e)
-
+	close(conns)
+	for co := range conns {
 		co.Close()
 	}
+
+	c.log().Info("goredis: pool closed", "addr", c.addr)
+
+	return nil
 }
 
 func (c *Client) Get() (*PoolConn, error) {
@@ -182,69 +467,142 @@ func (c *Client) Get() (*PoolConn, error) {
 		return nil, err
 	}
 
-	return &PoolConn{co, c}, err
+	return &PoolConn{Conn: co, c: c}, nil
 }
 
-func (c *Client) get() (co *Conn, err error) {
-	c.Lock()
-	if c.conns.Len() == 0 {
-		c.Unlock()
-
-		co, err = c.newConn(c.addr, c.password)
-	} else {
-		e := c.conns.Front()
-		co = e.Value.(*Conn)
-		c.conns.Remove(e)
+// Len reports how many idle connections are currently sitting in the pool.
+func (c *Client) Len() int {
+	c.mu.RLock()
+	conns := c.conns
+	c.mu.RUnlock()
 
-		c.Unlock()
+	if conns == nil {
+		return 0
 	}
-
-	return
+	return len(conns)
 }
 
-func (c *Client) put(conn *Conn) {
-	c.Lock()
-	defer c.Unlock()
+func (c *Client) get() (*Conn, error) {
+	if atomic.LoadInt32(&c.shuttingDown) != 0 {
+		return nil, ErrClosed
+	}
 
-	for c.conns.Len() >= c.maxIdleConns {
-		// remove back
-		e := c.conns.Back()
-		co := e.Value.(*Conn)
-		c.conns.Remove(e)
+	c.init()
 
-		co.Close()
+	c.mu.RLock()
+	conns, factory := c.conns, c.factory
+	c.mu.RUnlock()
+
+	if conns == nil {
+		return nil, ErrClosed
 	}
 
-	c.conns.PushFront(conn)
+	select {
+	case co := <-conns:
+		if co == nil {
+			return nil, ErrClosed
+		}
+		atomic.AddInt64(&c.hits, 1)
+		c.log().Debug("goredis: conn acquired", "addr", c.addr, "pool_size", len(conns))
+		return co, nil
+	default:
+		atomic.AddInt64(&c.misses, 1)
+		co, err := factory()
+		if err != nil {
+			return nil, err
+		}
+		atomic.AddInt64(&c.totalConns, 1)
+		c.log().Debug("goredis: conn dialed", "addr", c.addr, "pool_size", len(conns))
+		return co, nil
+	}
 }
 
-func (c *Client) getIdle() *Conn {
-	c.Lock()
-	defer c.Unlock()
+// Put returns conn to the pool. If the pool is closed or full, conn is
+// closed instead.
+//
+// The RLock is held across the whole send, not just the c.conns read: Close
+// takes the write lock before nil-ing and closing the channel, so holding
+// the RLock here is what guarantees Close can't close the channel out from
+// under an in-flight "conns <- conn" and panic with "send on closed channel".
+func (c *Client) Put(conn *Conn) error {
+	if conn == nil {
+		return errors.New("goredis: nil conn")
+	}
+
+	if atomic.LoadInt32(&c.shuttingDown) != 0 {
+		conn.Close()
+		return ErrClosed
+	}
+
+	c.init()
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	conns := c.conns
+	if conns == nil {
+		conn.Close()
+		return ErrClosed
+	}
 
-	if c.conns.Len() == 0 {
+	select {
+	case conns <- conn:
+		c.log().Debug("goredis: conn released", "addr", c.addr, "pool_size", len(conns))
+		return nil
+	default:
+		// pool is at capacity
+		conn.Close()
 		return nil
-	} else {
-		e := c.conns.Back()
-		co := e.Value.(*Conn)
-		c.conns.Remove(e)
-		return co
 	}
 }
 
 func (c *Client) checkIdle() {
-	co := c.getIdle()
-	if co == nil {
+	c.mu.RLock()
+	conns := c.conns
+	c.mu.RUnlock()
+
+	if conns == nil {
 		return
 	}
 
-	_, err := co.Do("PING")
-	if err != nil {
-		co.Close()
+	n := len(conns)
+	idle := make([]*Conn, 0, n)
+drain:
+	for i := 0; i < n; i++ {
+		select {
+		case co := <-conns:
+			// A closed conns channel always "wins" a select and yields the
+			// zero value. Close waits for onCheck to return before closing
+			// conns, so that is not expected here; the check mirrors get()'s
+			// guard all the same.
+			if co == nil {
+				break drain
+			}
+			idle = append(idle, co)
+		default:
+			break drain
+		}
+	}
 
-	} else {
-		c.put(co)
+	sem := make(chan struct{}, checkIdleConcurrency)
+	var wg sync.WaitGroup
+	for _, co := range idle {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(co *Conn) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if _, err := co.Do("PING"); err != nil {
+				atomic.AddInt64(&c.staleClosed, 1)
+				c.log().Warn("goredis: idle conn ping failed", "addr", co.RemoteAddr(), "err", err)
+				co.Close()
+			} else {
+				c.Put(co)
+			}
+		}(co)
 	}
+	wg.Wait()
 }
 
 func (c *Client) onCheck() {
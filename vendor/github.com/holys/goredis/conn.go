@@ -0,0 +1,195 @@
+package goredis
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// Conn is a single connection to a redis-protocol server: a net.Conn plus
+// buffered RESP reader/writer state. It is the unit Client pools, retries
+// and hands out via Get/PoolConn.
+type Conn struct {
+	nc net.Conn
+	br *bufio.Reader
+	bw *bufio.Writer
+
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+
+	closed bool
+}
+
+// newConn dials addr and authenticates with password, honoring the TLS
+// config and dial/read/write timeouts configured on c via ClientOptions or
+// NewClientFromURI.
+func (c *Client) newConn(addr, password string) (*Conn, error) {
+	proto := getProto(addr)
+	dialer := &net.Dialer{Timeout: c.dialTimeout}
+
+	var nc net.Conn
+	var err error
+	if c.tlsConfig != nil {
+		nc, err = tls.DialWithDialer(dialer, proto, addr, c.tlsConfig)
+	} else {
+		nc, err = dialer.Dial(proto, addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	co := &Conn{
+		nc:           nc,
+		br:           bufio.NewReaderSize(nc, c.readBufferSize),
+		bw:           bufio.NewWriterSize(nc, c.writeBufferSize),
+		readTimeout:  c.readTimeout,
+		writeTimeout: c.writeTimeout,
+	}
+
+	if password != "" {
+		if _, err := co.Do("AUTH", password); err != nil {
+			co.Close()
+			return nil, err
+		}
+	}
+
+	return co, nil
+}
+
+func (c *Conn) isClosed() bool {
+	return c.closed
+}
+
+// Close closes the underlying connection. Safe to call more than once.
+func (c *Conn) Close() {
+	if c.closed {
+		return
+	}
+	c.closed = true
+	c.nc.Close()
+}
+
+// SetDeadline sets the read/write deadline on the underlying net.Conn, so a
+// context.Context can abort an in-flight command.
+func (c *Conn) SetDeadline(t time.Time) error {
+	return c.nc.SetDeadline(t)
+}
+
+// RemoteAddr reports the address this conn is talking to.
+func (c *Conn) RemoteAddr() string {
+	return c.nc.RemoteAddr().String()
+}
+
+// Send writes cmd/args as a RESP array without reading a reply; used for
+// commands whose replies are read separately, e.g. MONITOR/SUBSCRIBE.
+func (c *Conn) Send(cmd string, args ...interface{}) error {
+	if c.writeTimeout > 0 {
+		c.nc.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+	}
+
+	if err := c.writeCommand(cmd, args...); err != nil {
+		return err
+	}
+	return c.bw.Flush()
+}
+
+// Do writes cmd/args and waits for and parses a single reply.
+func (c *Conn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	if err := c.Send(cmd, args...); err != nil {
+		return nil, err
+	}
+	return c.Receive()
+}
+
+func (c *Conn) writeCommand(cmd string, args ...interface{}) error {
+	if _, err := fmt.Fprintf(c.bw, "*%d\r\n", len(args)+1); err != nil {
+		return err
+	}
+	if err := writeBulk(c.bw, cmd); err != nil {
+		return err
+	}
+	for _, a := range args {
+		if err := writeBulk(c.bw, fmt.Sprint(a)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeBulk(w *bufio.Writer, s string) error {
+	_, err := fmt.Fprintf(w, "$%d\r\n%s\r\n", len(s), s)
+	return err
+}
+
+// Receive reads and parses a single RESP reply from the connection.
+func (c *Conn) Receive() (interface{}, error) {
+	if c.readTimeout > 0 {
+		c.nc.SetReadDeadline(time.Now().Add(c.readTimeout))
+	}
+
+	line, err := c.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, errors.New("goredis: empty reply line")
+	}
+
+	switch line[0] {
+	case '+':
+		return string(line[1:]), nil
+	case '-':
+		return nil, errors.New(string(line[1:]))
+	case ':':
+		return strconv.ParseInt(string(line[1:]), 10, 64)
+	case '$':
+		n, err := strconv.Atoi(string(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.br, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(string(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		arr := make([]interface{}, n)
+		for i := 0; i < n; i++ {
+			v, err := c.Receive()
+			if err != nil {
+				return nil, err
+			}
+			arr[i] = v
+		}
+		return arr, nil
+	default:
+		return nil, fmt.Errorf("goredis: unexpected reply prefix %q", line[0])
+	}
+}
+
+func (c *Conn) readLine() ([]byte, error) {
+	line, err := c.br.ReadBytes('\n')
+	if err != nil {
+		return nil, err
+	}
+	n := len(line)
+	if n >= 2 && line[n-2] == '\r' {
+		return line[:n-2], nil
+	}
+	return line[:n-1], nil
+}
@@ -0,0 +1,118 @@
+package goredis
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+)
+
+// Logger is the leveled logging interface Client emits diagnostic events
+// through. kv is alternating key/value pairs, e.g. Logger.Warn("ping
+// failed", "addr", addr, "err", err).
+type Logger interface {
+	Trace(msg string, kv ...interface{})
+	Debug(msg string, kv ...interface{})
+	Info(msg string, kv ...interface{})
+	Warn(msg string, kv ...interface{})
+	Error(msg string, kv ...interface{})
+}
+
+// noopLogger is the default Logger: embedding the package costs nothing
+// until a caller opts in with Client.SetLogger.
+type noopLogger struct{}
+
+func (noopLogger) Trace(string, ...interface{}) {}
+func (noopLogger) Debug(string, ...interface{}) {}
+func (noopLogger) Info(string, ...interface{})  {}
+func (noopLogger) Warn(string, ...interface{})  {}
+func (noopLogger) Error(string, ...interface{}) {}
+
+// StdLogger adapts the standard library's log package to Logger.
+type StdLogger struct {
+	*log.Logger
+}
+
+// NewStdLogger wraps l as a Logger, using log.Default() if l is nil.
+func NewStdLogger(l *log.Logger) *StdLogger {
+	if l == nil {
+		l = log.Default()
+	}
+	return &StdLogger{l}
+}
+
+func (s *StdLogger) logf(level, msg string, kv ...interface{}) {
+	s.Printf("[%s] %s%s", level, msg, formatKV(kv))
+}
+
+func (s *StdLogger) Trace(msg string, kv ...interface{}) { s.logf("TRACE", msg, kv...) }
+func (s *StdLogger) Debug(msg string, kv ...interface{}) { s.logf("DEBUG", msg, kv...) }
+func (s *StdLogger) Info(msg string, kv ...interface{})  { s.logf("INFO", msg, kv...) }
+func (s *StdLogger) Warn(msg string, kv ...interface{})  { s.logf("WARN", msg, kv...) }
+func (s *StdLogger) Error(msg string, kv ...interface{}) { s.logf("ERROR", msg, kv...) }
+
+func formatKV(kv []interface{}) string {
+	if len(kv) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(kv); i += 2 {
+		b.WriteByte(' ')
+		b.WriteString(fmt.Sprint(kv[i]))
+		b.WriteByte('=')
+		if i+1 < len(kv) {
+			b.WriteString(fmt.Sprint(kv[i+1]))
+		}
+	}
+	return b.String()
+}
+
+// loggerBox is the concrete type stored in Client.loggerV. atomic.Value
+// requires every Store to use the same concrete type, but Logger
+// implementations vary (noopLogger, *StdLogger, a caller's own type), so the
+// Logger interface value is boxed in a fixed-type struct before storing.
+type loggerBox struct{ Logger }
+
+// SetLogger installs logger as the Client's diagnostic sink. Passing nil
+// restores the default no-op logger. Safe to call while the client is
+// already handling traffic: the logger is held in an atomic.Value, since
+// every Do/Get/Put call path reads it without taking c.mu.
+func (c *Client) SetLogger(logger Logger) {
+	if logger == nil {
+		logger = noopLogger{}
+	}
+	c.loggerV.Store(loggerBox{logger})
+}
+
+// log returns the currently installed Logger, defaulting to a no-op one
+// until SetLogger has been called.
+func (c *Client) log() Logger {
+	if b, ok := c.loggerV.Load().(loggerBox); ok {
+		return b.Logger
+	}
+	return noopLogger{}
+}
+
+// PoolStats is a point-in-time snapshot of Client's pool and dial counters,
+// suitable for exporting to Prometheus or similar.
+type PoolStats struct {
+	IdleConns   int64
+	TotalConns  int64
+	Hits        int64
+	Misses      int64
+	Timeouts    int64
+	StaleClosed int64
+}
+
+// Stats returns a snapshot of the pool's counters.
+func (c *Client) Stats() PoolStats {
+	return PoolStats{
+		IdleConns:   int64(c.Len()),
+		TotalConns:  atomic.LoadInt64(&c.totalConns),
+		Hits:        atomic.LoadInt64(&c.hits),
+		Misses:      atomic.LoadInt64(&c.misses),
+		Timeouts:    atomic.LoadInt64(&c.timeouts),
+		StaleClosed: atomic.LoadInt64(&c.staleClosed),
+	}
+}
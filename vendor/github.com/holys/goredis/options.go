@@ -0,0 +1,161 @@
+package goredis
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ClientOptions configures a Client. Zero values fall back to the same
+// defaults NewClient uses.
+type ClientOptions struct {
+	Addr     string
+	Password string
+	DB       int
+
+	TLSConfig *tls.Config
+
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+
+	MaxIdleConns int
+	IdleTimeout  time.Duration
+
+	ReadBufferSize  int
+	WriteBufferSize int
+}
+
+// NewClientWithOptions builds a Client from a fully populated ClientOptions,
+// letting callers set TLS, timeouts and pool sizing that NewClient's
+// addr/password shorthand doesn't expose.
+func NewClientWithOptions(opts *ClientOptions) *Client {
+	c := new(Client)
+
+	c.addr = opts.Addr
+	c.password = opts.Password
+	c.db = opts.DB
+
+	c.tlsConfig = opts.TLSConfig
+	c.dialTimeout = opts.DialTimeout
+	c.readTimeout = opts.ReadTimeout
+	c.writeTimeout = opts.WriteTimeout
+	c.idleTimeout = opts.IdleTimeout
+
+	c.readBufferSize = opts.ReadBufferSize
+	if c.readBufferSize == 0 {
+		c.readBufferSize = 2048
+	}
+	c.writeBufferSize = opts.WriteBufferSize
+	if c.writeBufferSize == 0 {
+		c.writeBufferSize = 2048
+	}
+
+	c.initialCap = 1
+	c.maxCap = opts.MaxIdleConns
+	if c.maxCap == 0 {
+		c.maxCap = 10
+	}
+	c.factory = c.defaultFactory
+	c.maxRetries = 3
+	c.loggerV.Store(loggerBox{noopLogger{}})
+
+	c.quit = make(chan struct{})
+
+	return c
+}
+
+// NewClientFromURI builds a Client from a redis connection URI:
+//
+//	redis://[:password@]host:port[/db][?query]
+//	rediss://[:password@]host:port[/db][?query]  (TLS)
+//	unix://[:password@]/path/to.sock[?db=N&query]
+//
+// Recognized query parameters: ssl (bool, forces TLS), db (int, overrides
+// the path segment), dial_timeout/read_timeout/write_timeout/idle_timeout
+// (duration strings such as "500ms" or "5s") and pool_size (int, maps to
+// MaxIdleConns). This lets the package be pointed at managed Redis
+// (ElastiCache, Upstash, …) purely from a connection string.
+func NewClientFromURI(uri string) (*Client, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("goredis: invalid uri: %v", err)
+	}
+
+	opts := &ClientOptions{}
+
+	switch u.Scheme {
+	case "redis":
+		opts.Addr = u.Host
+	case "rediss":
+		opts.Addr = u.Host
+		opts.TLSConfig = &tls.Config{}
+	case "unix":
+		opts.Addr = u.Path
+	default:
+		return nil, fmt.Errorf("goredis: unsupported uri scheme %q", u.Scheme)
+	}
+
+	if u.User != nil {
+		if pass, ok := u.User.Password(); ok {
+			opts.Password = pass
+		} else if user := u.User.Username(); user != "" {
+			opts.Password = user
+		}
+	}
+
+	if u.Scheme != "unix" {
+		if db := strings.Trim(u.Path, "/"); db != "" {
+			n, err := strconv.Atoi(db)
+			if err != nil {
+				return nil, fmt.Errorf("goredis: invalid db %q: %v", db, err)
+			}
+			opts.DB = n
+		}
+	}
+
+	q := u.Query()
+
+	if q.Get("ssl") == "true" && opts.TLSConfig == nil {
+		opts.TLSConfig = &tls.Config{}
+	}
+
+	if db := q.Get("db"); db != "" {
+		n, err := strconv.Atoi(db)
+		if err != nil {
+			return nil, fmt.Errorf("goredis: invalid db %q: %v", db, err)
+		}
+		opts.DB = n
+	}
+
+	durations := map[string]*time.Duration{
+		"dial_timeout":  &opts.DialTimeout,
+		"read_timeout":  &opts.ReadTimeout,
+		"write_timeout": &opts.WriteTimeout,
+		"idle_timeout":  &opts.IdleTimeout,
+	}
+	for key, field := range durations {
+		v := q.Get(key)
+		if v == "" {
+			continue
+		}
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("goredis: invalid %s %q: %v", key, v, err)
+		}
+		*field = d
+	}
+
+	if v := q.Get("pool_size"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("goredis: invalid pool_size %q: %v", v, err)
+		}
+		opts.MaxIdleConns = n
+	}
+
+	return NewClientWithOptions(opts), nil
+}
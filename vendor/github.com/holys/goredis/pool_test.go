@@ -0,0 +1,256 @@
+package goredis
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newFakeConn wires a *Conn to an in-memory net.Pipe server goroutine that
+// replies +PONG to PING and +OK to everything else, so pool tests don't need
+// a real redis-server.
+func newFakeConn() *Conn {
+	client, server := net.Pipe()
+
+	go func() {
+		sc := &Conn{nc: server, br: bufio.NewReaderSize(server, 4096), bw: bufio.NewWriterSize(server, 4096)}
+		for {
+			reply, err := sc.Receive()
+			if err != nil {
+				return
+			}
+			args, _ := reply.([]interface{})
+			if len(args) > 0 && toString(args[0]) == "PING" {
+				fmt.Fprint(server, "+PONG\r\n")
+			} else {
+				fmt.Fprint(server, "+OK\r\n")
+			}
+		}
+	}()
+
+	return &Conn{nc: client, br: bufio.NewReaderSize(client, 4096), bw: bufio.NewWriterSize(client, 4096)}
+}
+
+// newHangingFakeConn reads the one command a caller sends and then never
+// replies, so tests can exercise context deadlines/cancellation.
+func newHangingFakeConn() *Conn {
+	client, server := net.Pipe()
+
+	go func() {
+		sc := &Conn{nc: server, br: bufio.NewReaderSize(server, 4096), bw: bufio.NewWriterSize(server, 4096)}
+		sc.Receive()
+		<-make(chan struct{})
+	}()
+
+	return &Conn{nc: client, br: bufio.NewReaderSize(client, 4096), bw: bufio.NewWriterSize(client, 4096)}
+}
+
+// newBlockingFakeConn replies +OK only once release is closed, so tests can
+// hold a Do call in flight on purpose.
+func newBlockingFakeConn(release <-chan struct{}) *Conn {
+	client, server := net.Pipe()
+
+	go func() {
+		sc := &Conn{nc: server, br: bufio.NewReaderSize(server, 4096), bw: bufio.NewWriterSize(server, 4096)}
+		if _, err := sc.Receive(); err != nil {
+			return
+		}
+		<-release
+		fmt.Fprint(server, "+OK\r\n")
+	}()
+
+	return &Conn{nc: client, br: bufio.NewReaderSize(client, 4096), bw: bufio.NewWriterSize(client, 4096)}
+}
+
+func TestPoolGetPutOverflow(t *testing.T) {
+	c := NewClientWithOptions(&ClientOptions{MaxIdleConns: 1})
+	c.SetFactory(func() (*Conn, error) { return newFakeConn(), nil })
+	c.SetInitialIdleConns(0)
+	defer c.Close()
+
+	co1, err := c.Get()
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got := c.Len(); got != 0 {
+		t.Fatalf("Len before any Put: want 0, got %d", got)
+	}
+
+	co1.Close()
+	if got := c.Len(); got != 1 {
+		t.Fatalf("Len after one Put: want 1, got %d", got)
+	}
+
+	co2, err := c.Get()
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	co3, err := c.Get()
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	// MaxIdleConns is 1: putting two conns back should keep only one idle
+	// and discard the other instead of growing the channel past capacity.
+	co2.Close()
+	co3.Close()
+	if got := c.Len(); got != 1 {
+		t.Fatalf("Len after overflow: want 1, got %d", got)
+	}
+}
+
+// TestPutCloseRace is a regression test for the panic a reader found:
+// Put releasing its RLock before sending on c.conns let a concurrent Close
+// nil-and-close that channel out from under it, panicking with "send on
+// closed channel". Run with -race to also catch the data race directly.
+func TestPutCloseRace(t *testing.T) {
+	for i := 0; i < 50; i++ {
+		c := NewClientWithOptions(&ClientOptions{MaxIdleConns: 4})
+		c.SetFactory(func() (*Conn, error) { return newFakeConn(), nil })
+		c.SetInitialIdleConns(0)
+
+		co, err := c.Get()
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			co.Close()
+		}()
+		go func() {
+			defer wg.Done()
+			c.Close()
+		}()
+		wg.Wait()
+	}
+}
+
+func TestDoContextCancellation(t *testing.T) {
+	c := NewClientWithOptions(&ClientOptions{MaxIdleConns: 1})
+	c.SetFactory(func() (*Conn, error) { return newHangingFakeConn(), nil })
+	c.SetInitialIdleConns(0)
+	c.SetMaxRetries(1)
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := c.DoContext(ctx, "GET", "k"); err == nil {
+		t.Fatal("DoContext: want error from a canceled context, got nil")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("DoContext took %v to respect the context deadline", elapsed)
+	}
+}
+
+func TestShutdownDrainsInFlight(t *testing.T) {
+	release := make(chan struct{})
+	c := NewClientWithOptions(&ClientOptions{MaxIdleConns: 1})
+	c.SetFactory(func() (*Conn, error) { return newBlockingFakeConn(release), nil })
+	c.SetInitialIdleConns(0)
+
+	done := make(chan error, 1)
+	go func() { _, err := c.Do("GET", "k"); done <- err }()
+
+	// give Do time to call c.get() and register with inFlight
+	time.Sleep(20 * time.Millisecond)
+
+	shutdownDone := make(chan error, 1)
+	go func() { shutdownDone <- c.Shutdown(context.Background()) }()
+
+	select {
+	case <-shutdownDone:
+		t.Fatal("Shutdown returned before the in-flight Do finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Do: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Do never returned after release")
+	}
+
+	select {
+	case err := <-shutdownDone:
+		if err != nil {
+			t.Fatalf("Shutdown: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Shutdown never completed once the in-flight Do drained")
+	}
+}
+
+// newDropAfterSubscribeConn reads one SUBSCRIBE and then closes the
+// connection, simulating a dropped Pub/Sub connection.
+func newDropAfterSubscribeConn() *Conn {
+	client, server := net.Pipe()
+
+	go func() {
+		sc := &Conn{nc: server, br: bufio.NewReaderSize(server, 4096), bw: bufio.NewWriterSize(server, 4096)}
+		sc.Receive()
+		server.Close()
+	}()
+
+	return &Conn{nc: client, br: bufio.NewReaderSize(client, 4096), bw: bufio.NewWriterSize(client, 4096)}
+}
+
+// newMessageDeliveringConn reads one SUBSCRIBE and pushes a single message
+// reply, then stays open.
+func newMessageDeliveringConn(channel, payload string) *Conn {
+	client, server := net.Pipe()
+
+	go func() {
+		sc := &Conn{nc: server, br: bufio.NewReaderSize(server, 4096), bw: bufio.NewWriterSize(server, 4096)}
+		sc.Receive()
+		fmt.Fprintf(server, "*3\r\n$7\r\nmessage\r\n$%d\r\n%s\r\n$%d\r\n%s\r\n", len(channel), channel, len(payload), payload)
+		<-make(chan struct{})
+	}()
+
+	return &Conn{nc: client, br: bufio.NewReaderSize(client, 4096), bw: bufio.NewWriterSize(client, 4096)}
+}
+
+// TestPubSubReconnectAndReplay exercises the reconnect-and-replay path: the
+// first dialed conn drops right after SUBSCRIBE, so the reader must reconnect
+// and resend SUBSCRIBE on the replacement before a message can arrive.
+func TestPubSubReconnectAndReplay(t *testing.T) {
+	var calls int32
+	c := NewClientWithOptions(&ClientOptions{})
+	c.SetFactory(func() (*Conn, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			return newDropAfterSubscribeConn(), nil
+		}
+		return newMessageDeliveringConn("news", "hello"), nil
+	})
+
+	ps, err := c.Subscribe("news")
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	defer ps.Close()
+
+	select {
+	case msg, ok := <-ps.Channel():
+		if !ok {
+			t.Fatal("Channel closed before delivering a message")
+		}
+		if msg.Channel != "news" || msg.Payload != "hello" {
+			t.Fatalf("unexpected message: %+v", msg)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for a message after reconnect")
+	}
+}
@@ -0,0 +1,387 @@
+package goredis
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// reconnectAttempts/reconnectBackoff bound how hard a PubSub retries after
+// its connection drops before giving up and closing its Channel().
+// pubSubPingInterval is how often the reader's companion goroutine sends a
+// keepalive PING on the dedicated conn, so a half-dead connection (accepted
+// by the OS, silently dropped by a middlebox) is detected instead of hanging
+// both sides until something else notices.
+const (
+	reconnectAttempts  = 3
+	reconnectBackoff   = 200 * time.Millisecond
+	pubSubPingInterval = 30 * time.Second
+)
+
+// Message is a payload delivered to a Pub/Sub subscriber. Pattern is only
+// set for messages received via PSubscribe.
+type Message struct {
+	Channel string
+	Pattern string
+	Payload string
+}
+
+// PubSub is an active Pub/Sub session returned by Client.Subscribe and
+// Client.PSubscribe. It owns a dedicated connection that is never returned
+// to the pool, so a slow subscriber can't starve Client.Do callers, and a
+// reader goroutine keeps it alive across transient network drops.
+type PubSub struct {
+	c *Client
+
+	mu       sync.Mutex // guards co and the subscription set below
+	co       *Conn
+	channels map[string]struct{}
+	patterns map[string]struct{}
+
+	msgs   chan *Message
+	closed chan struct{}
+	once   sync.Once
+}
+
+// Subscribe opens a PubSub subscribed to channels.
+func (c *Client) Subscribe(channels ...string) (*PubSub, error) {
+	return c.newPubSub(channels, nil)
+}
+
+// PSubscribe opens a PubSub subscribed to glob-style patterns.
+func (c *Client) PSubscribe(patterns ...string) (*PubSub, error) {
+	return c.newPubSub(nil, patterns)
+}
+
+func (c *Client) newPubSub(channels, patterns []string) (*PubSub, error) {
+	co, err := c.factory()
+	if err != nil {
+		return nil, err
+	}
+
+	ps := &PubSub{
+		c:        c,
+		co:       co,
+		channels: make(map[string]struct{}, len(channels)),
+		patterns: make(map[string]struct{}, len(patterns)),
+		msgs:     make(chan *Message, 100),
+		closed:   make(chan struct{}),
+	}
+
+	if err := ps.writeSubscribe("SUBSCRIBE", channels); err != nil {
+		co.Close()
+		return nil, err
+	}
+	if err := ps.writeSubscribe("PSUBSCRIBE", patterns); err != nil {
+		co.Close()
+		return nil, err
+	}
+
+	for _, ch := range channels {
+		ps.channels[ch] = struct{}{}
+	}
+	for _, p := range patterns {
+		ps.patterns[p] = struct{}{}
+	}
+
+	go ps.readLoop()
+	go ps.pingLoop()
+
+	return ps, nil
+}
+
+// Channel returns the stream of messages delivered to this subscription.
+// It is closed once the PubSub is closed or its reader gives up reconnecting.
+func (ps *PubSub) Channel() <-chan *Message {
+	return ps.msgs
+}
+
+// Subscribe adds channels to this PubSub's subscription set.
+func (ps *PubSub) Subscribe(ctx context.Context, channels ...string) error {
+	if err := ps.send(ctx, "SUBSCRIBE", channels); err != nil {
+		return err
+	}
+
+	ps.mu.Lock()
+	for _, ch := range channels {
+		ps.channels[ch] = struct{}{}
+	}
+	ps.mu.Unlock()
+	return nil
+}
+
+// Unsubscribe removes channels from this PubSub's subscription set.
+func (ps *PubSub) Unsubscribe(ctx context.Context, channels ...string) error {
+	if err := ps.send(ctx, "UNSUBSCRIBE", channels); err != nil {
+		return err
+	}
+
+	ps.mu.Lock()
+	for _, ch := range channels {
+		delete(ps.channels, ch)
+	}
+	ps.mu.Unlock()
+	return nil
+}
+
+// PSubscribe adds patterns to this PubSub's subscription set.
+func (ps *PubSub) PSubscribe(ctx context.Context, patterns ...string) error {
+	if err := ps.send(ctx, "PSUBSCRIBE", patterns); err != nil {
+		return err
+	}
+
+	ps.mu.Lock()
+	for _, p := range patterns {
+		ps.patterns[p] = struct{}{}
+	}
+	ps.mu.Unlock()
+	return nil
+}
+
+// PUnsubscribe removes patterns from this PubSub's subscription set.
+func (ps *PubSub) PUnsubscribe(ctx context.Context, patterns ...string) error {
+	if err := ps.send(ctx, "PUNSUBSCRIBE", patterns); err != nil {
+		return err
+	}
+
+	ps.mu.Lock()
+	for _, p := range patterns {
+		delete(ps.patterns, p)
+	}
+	ps.mu.Unlock()
+	return nil
+}
+
+// Close stops the reader goroutine and closes the dedicated connection.
+//
+// closed is closed and the current ps.co is read in the same critical
+// section reconnect uses to check closed before committing a freshly dialed
+// conn (see reconnect). That makes the two mutually exclusive: whichever
+// runs first under ps.mu determines which conn is "current" when the other
+// looks, so the conn left in ps.co always ends up the one Close closes —
+// never a reconnect winner that raced in after Close already ran.
+func (ps *PubSub) Close() error {
+	ps.once.Do(func() {
+		ps.mu.Lock()
+		close(ps.closed)
+		co := ps.co
+		ps.mu.Unlock()
+
+		co.Close()
+	})
+	return nil
+}
+
+// send writes cmd over the current connection, honoring ctx's deadline the
+// same way DoContext does. ps.mu is held across the whole write, not just
+// the ps.co read, so it can't interleave with pingLoop's keepalive PING (or
+// another send) writing into the same *bufio.Writer at the same time and
+// corrupting the RESP byte stream.
+func (ps *PubSub) send(ctx context.Context, cmd string, args []string) error {
+	if len(args) == 0 {
+		return nil
+	}
+
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	cancel := ps.c.applyDeadline(ctx, ps.co)
+	defer cancel()
+
+	return ps.writeSubscribeOn(ps.co, cmd, args)
+}
+
+// writeSubscribe is newPubSub's initial-subscribe path; see send's comment
+// on why ps.mu spans the whole write.
+func (ps *PubSub) writeSubscribe(cmd string, args []string) error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	return ps.writeSubscribeOn(ps.co, cmd, args)
+}
+
+func (ps *PubSub) writeSubscribeOn(co *Conn, cmd string, args []string) error {
+	if len(args) == 0 {
+		return nil
+	}
+
+	argv := make([]interface{}, len(args))
+	for i, a := range args {
+		argv[i] = a
+	}
+	return co.Send(cmd, argv...)
+}
+
+// readLoop pulls message/pmessage/subscribe/unsubscribe/pong arrays off the
+// dedicated conn and dispatches parsed Messages to ps.msgs, atop the same
+// runReadLoop primitive Monitor/MonitorContext use. A read error reconnects
+// and replays the subscription set instead of stopping the loop; giving up
+// (reconnect exhausted) or ps.closed firing are what actually end it.
+func (ps *PubSub) readLoop() {
+	defer close(ps.msgs)
+
+	for {
+		ps.mu.Lock()
+		co := ps.co
+		ps.mu.Unlock()
+
+		gaveUp := false
+		runReadLoop(ps.closed,
+			func() (interface{}, error) { return co.Receive() },
+			nil,
+			func(reply interface{}) bool {
+				msg, ok := parsePubSubReply(reply)
+				if !ok {
+					// subscribe/unsubscribe/pong acks carry no payload to deliver
+					return true
+				}
+
+				select {
+				case ps.msgs <- msg:
+					return true
+				case <-ps.closed:
+					return false
+				}
+			},
+			func(err error) bool {
+				ps.c.log().Warn("goredis: pubsub conn lost, reconnecting", "err", err)
+				if !ps.reconnect() {
+					ps.c.log().Error("goredis: pubsub reconnect exhausted, giving up", "channels", len(ps.channels), "patterns", len(ps.patterns))
+					gaveUp = true
+					return false
+				}
+				return false // stop this pass; the outer for loop re-reads ps.co and restarts
+			},
+		)
+
+		select {
+		case <-ps.closed:
+			return
+		default:
+		}
+		if gaveUp {
+			return
+		}
+	}
+}
+
+// pingLoop periodically sends a keepalive PING over the same mutex-guarded
+// write path writeSubscribe uses, so a dead-but-not-yet-errored connection
+// is detected by the next Send failing rather than by silence.
+func (ps *PubSub) pingLoop() {
+	t := time.NewTicker(pubSubPingInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			ps.mu.Lock()
+			co := ps.co
+			err := co.Send("PING")
+			ps.mu.Unlock()
+
+			if err != nil {
+				ps.c.log().Warn("goredis: pubsub ping failed", "err", err)
+			}
+		case <-ps.closed:
+			return
+		}
+	}
+}
+
+// reconnect dials a fresh conn and replays the current subscription set,
+// retrying a bounded number of times with a short backoff.
+func (ps *PubSub) reconnect() bool {
+	ps.mu.Lock()
+	channels := make([]string, 0, len(ps.channels))
+	for ch := range ps.channels {
+		channels = append(channels, ch)
+	}
+	patterns := make([]string, 0, len(ps.patterns))
+	for p := range ps.patterns {
+		patterns = append(patterns, p)
+	}
+	ps.mu.Unlock()
+
+	for attempt := 1; attempt <= reconnectAttempts; attempt++ {
+		select {
+		case <-ps.closed:
+			return false
+		default:
+		}
+
+		co, err := ps.c.factory()
+		if err != nil {
+			ps.c.log().Warn("goredis: pubsub reconnect attempt failed", "attempt", attempt, "err", err)
+			time.Sleep(reconnectBackoff)
+			continue
+		}
+
+		if err := ps.writeSubscribeOn(co, "SUBSCRIBE", channels); err != nil {
+			co.Close()
+			time.Sleep(reconnectBackoff)
+			continue
+		}
+		if err := ps.writeSubscribeOn(co, "PSUBSCRIBE", patterns); err != nil {
+			co.Close()
+			time.Sleep(reconnectBackoff)
+			continue
+		}
+
+		// Re-check closed under the same lock Close uses before committing
+		// the swap, so a Close racing with this reconnect can't lose track
+		// of whichever conn ends up "current" (see Close's doc comment).
+		ps.mu.Lock()
+		select {
+		case <-ps.closed:
+			ps.mu.Unlock()
+			co.Close()
+			return false
+		default:
+		}
+		ps.co = co
+		ps.mu.Unlock()
+
+		ps.c.log().Info("goredis: pubsub reconnected", "channels", len(channels), "patterns", len(patterns))
+		return true
+	}
+
+	return false
+}
+
+func parsePubSubReply(reply interface{}) (*Message, bool) {
+	parts, ok := reply.([]interface{})
+	if !ok || len(parts) == 0 {
+		return nil, false
+	}
+
+	kind := toString(parts[0])
+
+	switch kind {
+	case "message":
+		if len(parts) < 3 {
+			return nil, false
+		}
+		return &Message{Channel: toString(parts[1]), Payload: toString(parts[2])}, true
+	case "pmessage":
+		if len(parts) < 4 {
+			return nil, false
+		}
+		return &Message{Pattern: toString(parts[1]), Channel: toString(parts[2]), Payload: toString(parts[3])}, true
+	default:
+		// subscribe/unsubscribe/psubscribe/punsubscribe/pong acks
+		return nil, false
+	}
+}
+
+func toString(v interface{}) string {
+	switch s := v.(type) {
+	case string:
+		return s
+	case []byte:
+		return string(s)
+	default:
+		return fmt.Sprint(s)
+	}
+}
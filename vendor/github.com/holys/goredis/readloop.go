@@ -0,0 +1,40 @@
+package goredis
+
+// runReadLoop is the read/classify/handle shape shared by Monitor,
+// MonitorContext and PubSub's reader: call readOnce until stop fires or a
+// callback says to quit. onStop (optional) fires when stop wins a race with
+// an in-flight read or error. onReply/onError report whether the loop
+// should keep going.
+func runReadLoop(stop <-chan struct{}, readOnce func() (interface{}, error), onStop func(), onReply func(interface{}) bool, onError func(error) bool) {
+	for {
+		select {
+		case <-stop:
+			if onStop != nil {
+				onStop()
+			}
+			return
+		default:
+		}
+
+		reply, err := readOnce()
+		if err != nil {
+			select {
+			case <-stop:
+				if onStop != nil {
+					onStop()
+				}
+				return
+			default:
+			}
+
+			if !onError(err) {
+				return
+			}
+			continue
+		}
+
+		if !onReply(reply) {
+			return
+		}
+	}
+}